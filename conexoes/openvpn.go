@@ -2,12 +2,15 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"os"
 	"os/exec"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,12 +21,76 @@ import (
 const EASYRSA_VERSION = "3.1.0" // Versão estável
 const OPENVPN_VERSION = "2.5.4" // Versão estável
 
+// Caminho do ficheiro de estado que regista o backend TLS escolhido na instalação
+const multiflowStateFile = "/etc/openvpn/multiflow-state.json"
+
+// estadoMultiflow representa o estado persistido do módulo em /etc/openvpn/multiflow-state.json
+type estadoMultiflow struct {
+	TLSBackend     string `json:"tls_backend"`
+	ControlChannel string `json:"control_channel"` // tls-auth, tls-crypt ou tls-crypt-v2
+}
+
+// Caminho do socket unix usado pela interface de gestão do OpenVPN
+const managementSocket = "/etc/openvpn/management.sock"
+
+// Diretório base onde vive cada instância multi-servidor (/etc/openvpn/instances/<nome>/)
+const instancesBaseDir = "/etc/openvpn/instances"
+
+// instanciaOpenvpn representa uma instância de servidor OpenVPN independente,
+// com a sua própria porta, sub-rede e unidade systemd (openvpn@<nome>)
+type instanciaOpenvpn struct {
+	Nome   string
+	Porta  int
+	Subnet string
+}
+
+// Ficheiro onde os hooks registados são persistidos, e marcadores usados para
+// delimitar o bloco gerado automaticamente dentro de server.conf
+const hooksFile = "/etc/openvpn/hooks.json"
+const hooksBeginMarker = "# BEGIN multiflow-hooks"
+const hooksEndMarker = "# END multiflow-hooks"
+
+// Hook representa um plugin ou script associado a um evento do OpenVPN
+// (client-connect, client-disconnect, auth-user-pass-verify, ...)
+type Hook struct {
+	Event    string `json:"event"`
+	Type     string `json:"type"` // "plugin" ou "script"
+	Path     string `json:"path"`
+	Deferred bool   `json:"deferred"`
+}
+
+// multiflowConfig representa o ficheiro de configuração declarativo (multiflow.yaml)
+// usado no modo não interativo, para correr o multiflow como entrypoint de
+// contentor ou a partir de Ansible sem TTY
+type multiflowConfig struct {
+	Port           int
+	Proto          string
+	DNS            []string
+	Subnet         string
+	Iface          string
+	TLSBackend     string
+	ControlChannel string
+	Clients        []string
+}
+
+// clienteConectado representa uma linha de "status 3" devolvida pela interface de gestão
+type clienteConectado struct {
+	CommonName     string
+	RealAddress    string
+	VirtualAddress string
+	BytesSent      int64
+	BytesReceived  int64
+	ConnectedSince string
+}
+
 // Variáveis globais
 var (
 	isOpenVPNInstalled bool
 	osType             string
 	groupName          string
 	rcLocal            string
+	modoNaoInterativo  bool
+	configHeadless     *multiflowConfig
 	red                = color.New(color.FgHiRed).SprintFunc()
 	green              = color.New(color.FgHiGreen).SprintFunc()
 	yellow             = color.New(color.FgHiYellow).SprintFunc()
@@ -93,11 +160,27 @@ func funBar(cmdToRun string) {
 	}
 }
 
-// Verifica se uma porta está em uso
+// Verifica se uma porta está em uso. Em modo --non-interactive não há menu
+// nem TTY para onde voltar, por isso termina imediatamente em vez de cair no
+// menuPrincipal()
 func verifPtrs(porta int) {
+	for _, inst := range listarInstancias() {
+		if inst.Porta == porta {
+			fmt.Printf("\n%sA PORTA %s%d %sJÁ ESTÁ EM USO PELA INSTÂNCIA %s%s\n", red(""), yellow(""), porta, red(""), yellow(""), inst.Nome)
+			if modoNaoInterativo {
+				os.Exit(1)
+			}
+			time.Sleep(3 * time.Second)
+			menuPrincipal()
+		}
+	}
+
 	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", porta))
 	if err != nil {
 		fmt.Printf("\n%sA PORTA %s%d %sESTÁ EM USO%s\n", red(""), yellow(""), porta, red(""), scolor(""))
+		if modoNaoInterativo {
+			os.Exit(1)
+		}
 		time.Sleep(3 * time.Second)
 		menuPrincipal()
 	}
@@ -107,8 +190,16 @@ func verifPtrs(porta int) {
 }
 
 // Cria um novo ficheiro de configuração de cliente (.ovpn)
-func newClient(client string) {
-	clientCommon, err := ioutil.ReadFile("/etc/openvpn/client-common.txt")
+// newClient gera o .ovpn de um cliente. Quando instancia é "", usa o layout
+// de servidor único legado em /etc/openvpn; caso contrário, usa o
+// client-common.txt da instância indicada para que o "remote" e a CA
+// embutida correspondam à instância certa
+func newClient(client string, instancia string) {
+	clientCommonPath := "/etc/openvpn/client-common.txt"
+	if instancia != "" {
+		clientCommonPath = fmt.Sprintf("%s/%s/client-common.txt", instancesBaseDir, instancia)
+	}
+	clientCommon, err := ioutil.ReadFile(clientCommonPath)
 	if err != nil {
 		fmt.Println(red("Erro ao ler client-common.txt:"), err)
 		return
@@ -128,17 +219,49 @@ func newClient(client string) {
 		fmt.Println(red("Erro ao ler a chave do cliente:"), err)
 		return
 	}
-	tls, err := ioutil.ReadFile("/etc/openvpn/ta.key")
-	if err != nil {
-		fmt.Println(red("Erro ao ler ta.key:"), err)
-		return
+
+	// A tag embutida e a origem da chave do canal de controlo dependem do
+	// modo escolhido na instalação; tls-crypt-v2 usa uma chave própria por cliente
+	controlChannel := lerControlChannel()
+	var tagTLS, tls string
+	switch controlChannel {
+	case "tls-crypt":
+		tagTLS = "tls-crypt"
+		dados, err := ioutil.ReadFile("/etc/openvpn/ta.key")
+		if err != nil {
+			fmt.Println(red("Erro ao ler ta.key:"), err)
+			return
+		}
+		tls = string(dados)
+	case "tls-crypt-v2":
+		tagTLS = "tls-crypt-v2"
+		chaveCliente := fmt.Sprintf("/etc/openvpn/easy-rsa/pki/private/%s-tls-crypt-v2.pem", client)
+		cmd := exec.Command("openvpn", "--tls-crypt-v2", "/etc/openvpn/tls-crypt-v2-server.key", "--tls-crypt-v2-genkey", "client", chaveCliente)
+		if err := cmd.Run(); err != nil {
+			fmt.Println(red("Erro ao gerar a chave tls-crypt-v2 do cliente:"), err)
+			return
+		}
+		dados, err := ioutil.ReadFile(chaveCliente)
+		if err != nil {
+			fmt.Println(red("Erro ao ler a chave tls-crypt-v2 do cliente:"), err)
+			return
+		}
+		tls = string(dados)
+	default:
+		tagTLS = "tls-auth"
+		dados, err := ioutil.ReadFile("/etc/openvpn/ta.key")
+		if err != nil {
+			fmt.Println(red("Erro ao ler ta.key:"), err)
+			return
+		}
+		tls = string(dados)
 	}
 
 	content := string(clientCommon) +
 		"\n<ca>\n" + string(ca) + "</ca>\n" +
 		"<cert>\n" + string(cert) + "</cert>\n" +
 		"<key>\n" + string(key) + "</key>\n" +
-		"<tls-auth>\n" + string(tls) + "</tls-auth>\n"
+		fmt.Sprintf("<%s>\n%s</%s>\n", tagTLS, tls, tagTLS)
 
 	err = ioutil.WriteFile(fmt.Sprintf("/root/%s.ovpn", client), []byte(content), 0644)
 	if err != nil {
@@ -146,108 +269,1000 @@ func newClient(client string) {
 	}
 }
 
-// Função principal de instalação do OpenVPN
-func instalarOpenvpn() {
-	// Detetar IP público (simplificado)
-	conn, err := net.Dial("udp", "8.8.8.8:80")
+// Devolve o caminho do socket unix da interface de gestão do servidor por
+// omissão (instancia == "") ou de uma instância multi-servidor
+func socketGestao(instancia string) string {
+	if instancia == "" {
+		return managementSocket
+	}
+	return fmt.Sprintf("%s/%s/management.sock", instancesBaseDir, instancia)
+}
+
+// Liga-se ao socket unix da interface de gestão do OpenVPN, tentando algumas
+// vezes com backoff caso o daemon ainda esteja a arrancar
+func manageConnDial(socketPath string) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	backoff := 200 * time.Millisecond
+	for tentativa := 0; tentativa < 5; tentativa++ {
+		conn, err = net.Dial("unix", socketPath)
+		if err == nil {
+			return conn, nil
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	return nil, fmt.Errorf("não foi possível ligar ao socket de gestão %s: %w", socketPath, err)
+}
+
+// Envia um comando à interface de gestão do servidor indicado por socketPath
+// e devolve a resposta completa (o protocolo é line-based: as respostas
+// multi-linha terminam numa linha "END" e as de uma só linha começam por
+// "SUCCESS:" ou "ERROR:")
+func manageConnComando(socketPath string, cmd string) (string, error) {
+	conn, err := manageConnDial(socketPath)
 	if err != nil {
-		fmt.Println(red("Não foi possível determinar o endereço IP local."), err)
-		os.Exit(1)
+		return "", err
 	}
 	defer conn.Close()
-	ip := conn.LocalAddr().(*net.UDPAddr).IP.String()
 
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := fmt.Fprintf(conn, "%s\n", cmd); err != nil {
+		return "", fmt.Errorf("erro ao enviar comando de gestão: %w", err)
+	}
+
+	var resposta strings.Builder
+	leitor := bufio.NewReader(conn)
+	for {
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		linha, err := leitor.ReadString('\n')
+		resposta.WriteString(linha)
+		if err != nil {
+			return resposta.String(), fmt.Errorf("erro ao ler resposta de gestão: %w", err)
+		}
+		linha = strings.TrimSpace(linha)
+		if linha == "END" || strings.HasPrefix(linha, "SUCCESS:") || strings.HasPrefix(linha, "ERROR:") {
+			break
+		}
+	}
+	return resposta.String(), nil
+}
+
+// Lista os clientes atualmente ligados ao servidor indicado por socketPath,
+// interpretando a saída de "status 3"
+func manageConnListarClientes(socketPath string) ([]clienteConectado, error) {
+	resposta, err := manageConnComando(socketPath, "status 3")
+	if err != nil {
+		return nil, err
+	}
+
+	var clientes []clienteConectado
+	for _, linha := range strings.Split(resposta, "\n") {
+		linha = strings.TrimSpace(linha)
+		if !strings.HasPrefix(linha, "CLIENT_LIST") {
+			continue
+		}
+		campos := strings.Split(linha, "\t")
+		// "status 3" separa os campos por tabulações:
+		// CLIENT_LIST<TAB>cn<TAB>real_addr<TAB>virtual_addr<TAB>virtual_ipv6<TAB>bytes_received<TAB>bytes_sent<TAB>connected_since<TAB>...
+		if len(campos) < 8 {
+			continue
+		}
+		bytesRecv, _ := strconv.ParseInt(campos[5], 10, 64)
+		bytesSent, _ := strconv.ParseInt(campos[6], 10, 64)
+		clientes = append(clientes, clienteConectado{
+			CommonName:     campos[1],
+			RealAddress:    campos[2],
+			VirtualAddress: campos[3],
+			BytesReceived:  bytesRecv,
+			BytesSent:      bytesSent,
+			ConnectedSince: campos[7],
+		})
+	}
+	return clientes, nil
+}
+
+// Desliga um cliente ligado sem reiniciar o daemon
+func manageConnKill(socketPath string, commonName string) error {
+	_, err := manageConnComando(socketPath, fmt.Sprintf("kill %s", commonName))
+	return err
+}
+
+// Pergunta a que instância (servidor por omissão ou uma das instâncias
+// multi-servidor) uma operação de gestão se destina, da mesma forma que
+// criarCliente pergunta a que instância um novo cliente pertence
+func perguntarInstanciaGestao() string {
+	instancias := listarInstancias()
+	if len(instancias) == 0 {
+		return ""
+	}
+	fmt.Println(yellow("Instâncias disponíveis (deixe em branco para o servidor padrão):"))
+	for _, inst := range instancias {
+		fmt.Printf("  %s%s%s - Porta: %d\n", cyan(""), inst.Nome, scolor(""), inst.Porta)
+	}
+	return perguntarString(fmt.Sprintf("%sInstância a consultar: %s", green(""), white("")), "")
+}
+
+// Lista os clientes ligados através da interface de gestão
+func listarClientesConectados() {
 	clearScreen()
-	fmt.Println("\033[44;1;37m INSTALADOR OPENVPN \033[0m")
-	fmt.Println(green("A iniciar a instalação do OpenVPN..."))
+	instancia := perguntarInstanciaGestao()
+	fmt.Println(yellow("A consultar clientes ligados..."))
+	clientes, err := manageConnListarClientes(socketGestao(instancia))
+	if err != nil {
+		fmt.Println(red("Erro ao consultar a interface de gestão:"), err)
+		fmt.Println(green("Pressione ENTER para continuar..."))
+		bufio.NewReader(os.Stdin).ReadBytes('\n')
+		return
+	}
+	if len(clientes) == 0 {
+		fmt.Println(yellow("Nenhum cliente ligado neste momento."))
+	}
+	for _, c := range clientes {
+		fmt.Printf("%s%s%s - IP real: %s - IP virtual: %s - Ligado desde: %s - Enviado: %d bytes - Recebido: %d bytes\n",
+			green(""), c.CommonName, scolor(""), c.RealAddress, c.VirtualAddress, c.ConnectedSince, c.BytesSent, c.BytesReceived)
+	}
+	fmt.Println(green("Pressione ENTER para continuar..."))
+	bufio.NewReader(os.Stdin).ReadBytes('\n')
+}
 
-	// Instalação de dependências
-	if osType == "debian" {
-		funBar("apt-get update && apt-get install -y build-essential autoconf automake libtool pkg-config liblz4-dev liblzo2-dev libssl-dev iptables openssl ca-certificates wget tar")
+// Desliga forçadamente um cliente ligado via interface de gestão
+func desconectarCliente() {
+	clearScreen()
+	instancia := perguntarInstanciaGestao()
+	var client string
+	fmt.Printf("%sNome (CN) do cliente a desconectar: %s", green(""), white(""))
+	fmt.Scanln(&client)
+	if err := manageConnKill(socketGestao(instancia), client); err != nil {
+		fmt.Println(red("Erro ao desconectar o cliente:"), err)
 	} else {
-		funBar("yum groupinstall -y 'Development Tools' && yum install -y epel-release && yum install -y autoconf automake libtool pkgconfig lz4-devel lzo-devel openssl-devel iptables openssl ca-certificates wget tar")
+		fmt.Println(green("Cliente desconectado com sucesso."))
 	}
+	fmt.Println(green("Pressione ENTER para continuar..."))
+	bufio.NewReader(os.Stdin).ReadBytes('\n')
+}
 
-	// Baixar e instalar OpenVPN
-	fmt.Println(yellow("A baixar e compilar o OpenVPN..."))
-	funBar(fmt.Sprintf("wget -O /tmp/openvpn.tar.gz https://swupdate.openvpn.org/community/releases/openvpn-%s.tar.gz && tar -xzf /tmp/openvpn.tar.gz -C /tmp && cd /tmp/openvpn-%s && ./configure && make && make install", OPENVPN_VERSION, OPENVPN_VERSION))
+// Revoga o certificado de um cliente e aplica a CRL sem reiniciar o daemon
+func revogarCertificadoCliente() {
+	clearScreen()
+	instancia := perguntarInstanciaGestao()
+	var client string
+	fmt.Printf("%sNome (CN) do cliente a revogar: %s", green(""), white(""))
+	fmt.Scanln(&client)
 
-	// Baixar e configurar EasyRSA
-	fmt.Println(yellow("A baixar e configurar o EasyRSA..."))
-	os.MkdirAll("/etc/openvpn/easy-rsa", 0755)
-	funBar(fmt.Sprintf("wget -O /tmp/easyrsa.tgz https://github.com/OpenVPN/easy-rsa/releases/download/v%s/EasyRSA-%s.tgz && tar -xzf /tmp/easyrsa.tgz -C /tmp && mv /tmp/EasyRSA-%s/* /etc/openvpn/easy-rsa/", EASYRSA_VERSION, EASYRSA_VERSION, EASYRSA_VERSION))
-	
 	os.Chdir("/etc/openvpn/easy-rsa/")
-	funBar("./easyrsa init-pki && ./easyrsa --batch build-ca nopass && ./easyrsa gen-dh && ./easyrsa build-server-full server nopass && ./easyrsa build-client-full client nopass && ./easyrsa gen-crl")
+	if err := exec.Command("./easyrsa", "--batch", "revoke", client).Run(); err != nil {
+		fmt.Println(red("Falha ao revogar o certificado:"), err)
+		return
+	}
+	if err := exec.Command("./easyrsa", "gen-crl").Run(); err != nil {
+		fmt.Println(red("Falha ao gerar a CRL:"), err)
+		return
+	}
+	if err := exec.Command("cp", "pki/crl.pem", "/etc/openvpn/crl.pem").Run(); err != nil {
+		fmt.Println(red("Falha ao copiar a CRL:"), err)
+		return
+	}
 
-	// Mover ficheiros e configurar permissões
-	filesToCopy := []string{"pki/ca.crt", "pki/private/ca.key", "pki/dh.pem", "pki/issued/server.crt", "pki/private/server.key", "pki/crl.pem"}
-	for _, f := range filesToCopy {
-		exec.Command("cp", f, "/etc/openvpn").Run()
+	if err := manageConnKill(socketGestao(instancia), client); err != nil {
+		fmt.Println(yellow("CRL atualizada, mas não foi possível desligar o cliente já ligado:"), err)
+	} else {
+		fmt.Println(green("Certificado revogado, CRL atualizada e cliente desligado."))
 	}
-	exec.Command("chown", "nobody:"+groupName, "/etc/openvpn/crl.pem").Run()
-	exec.Command("openvpn", "--genkey", "--secret", "/etc/openvpn/ta.key").Run()
+	fmt.Println(green("Pressione ENTER para continuar..."))
+	bufio.NewReader(os.Stdin).ReadBytes('\n')
+}
 
-	// Configuração interativa
-	var port int
-	fmt.Printf("%sQual porta deseja usar para o OpenVPN? %s[padrão: 1194]: %s", green(""), yellow(""), white(""))
-	fmt.Scanf("%d\n", &port)
-	if port == 0 {
-		port = 1194
+// Lê os hooks registados em hooksFile, devolvendo uma lista vazia se o ficheiro não existir
+func lerHooks() []Hook {
+	dados, err := ioutil.ReadFile(hooksFile)
+	if err != nil {
+		return nil
+	}
+	var hooks []Hook
+	if err := json.Unmarshal(dados, &hooks); err != nil {
+		return nil
+	}
+	return hooks
+}
+
+// Grava a lista de hooks em hooksFile
+func gravarHooks(hooks []Hook) error {
+	dados, err := json.MarshalIndent(hooks, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(hooksFile, dados, 0644)
+}
+
+// Gera as linhas de server.conf correspondentes aos hooks registados
+func gerarLinhasHooks(hooks []Hook) []string {
+	if len(hooks) == 0 {
+		return nil
+	}
+	linhas := []string{hooksBeginMarker, "script-security 2"}
+	for _, h := range hooks {
+		deferredSufixo := ""
+		if h.Deferred {
+			deferredSufixo = " defer"
+		}
+		switch h.Event {
+		case "auth-user-pass-verify":
+			if h.Type == "plugin" {
+				linhas = append(linhas, fmt.Sprintf("plugin %s", h.Path))
+			} else {
+				linhas = append(linhas, fmt.Sprintf("auth-user-pass-verify %s via-file%s", h.Path, deferredSufixo))
+			}
+		default:
+			if h.Type == "plugin" {
+				linhas = append(linhas, fmt.Sprintf("plugin %s", h.Path))
+			} else {
+				linhas = append(linhas, fmt.Sprintf("%s %s", h.Event, h.Path))
+			}
+		}
+	}
+	linhas = append(linhas, hooksEndMarker)
+	return linhas
+}
+
+// Reescreve o server.conf indicado (o do servidor por omissão ou o de uma
+// instância) substituindo o bloco de hooks anterior (se existir) pelo atual
+func aplicarHooksAoServerConf(serverConfPath string) error {
+	dados, err := ioutil.ReadFile(serverConfPath)
+	if err != nil {
+		return err
+	}
+
+	var base []string
+	dentroDoBloco := false
+	for _, linha := range strings.Split(string(dados), "\n") {
+		if strings.TrimSpace(linha) == hooksBeginMarker {
+			dentroDoBloco = true
+			continue
+		}
+		if strings.TrimSpace(linha) == hooksEndMarker {
+			dentroDoBloco = false
+			continue
+		}
+		if dentroDoBloco {
+			continue
+		}
+		base = append(base, linha)
 	}
-	verifPtrs(port)
 
-	var protocol string
-	fmt.Printf("%sQual protocolo? %s[1] UDP (recomendado) [2] TCP: %s", green(""), yellow(""), white(""))
-	var protoChoice int
-	fmt.Scanf("%d\n", &protoChoice)
-	if protoChoice == 2 {
-		protocol = "tcp"
+	conteudo := strings.TrimRight(strings.Join(base, "\n"), "\n")
+	novasLinhas := gerarLinhasHooks(lerHooks())
+	if len(novasLinhas) > 0 {
+		conteudo += "\n" + strings.Join(novasLinhas, "\n") + "\n"
 	} else {
-		protocol = "udp"
+		conteudo += "\n"
 	}
+	return ioutil.WriteFile(serverConfPath, []byte(conteudo), 0644)
+}
 
-	var dns1, dns2 string
-	fmt.Printf("%sQual DNS usar? %s[1] Google (padrão) [2] Cloudflare [3] OpenDNS: %s", green(""), yellow(""), white(""))
-	var dnsChoice int
-	fmt.Scanf("%d\n", &dnsChoice)
-	switch dnsChoice {
+// Aplica os hooks registados ao server.conf do servidor por omissão (se
+// existir) e ao de cada instância multi-servidor configurada
+func aplicarHooksATodosOsServidores() error {
+	if _, err := os.Stat("/etc/openvpn/server.conf"); err == nil {
+		if err := aplicarHooksAoServerConf("/etc/openvpn/server.conf"); err != nil {
+			return err
+		}
+	}
+	for _, inst := range listarInstancias() {
+		caminho := fmt.Sprintf("%s/%s/server.conf", instancesBaseDir, inst.Nome)
+		if err := aplicarHooksAoServerConf(caminho); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Menu para registar um novo hook de client-connect/client-disconnect/auth-user-pass-verify
+func registarHook() {
+	clearScreen()
+	fmt.Printf("%sEvento %s[1] client-connect [2] client-disconnect [3] auth-user-pass-verify: %s", green(""), yellow(""), white(""))
+	var escolhaEvento int
+	fmt.Scanf("%d\n", &escolhaEvento)
+	var evento string
+	switch escolhaEvento {
 	case 2:
-		dns1, dns2 = "1.1.1.1", "1.0.0.1"
+		evento = "client-disconnect"
 	case 3:
-		dns1, dns2 = "208.67.222.222", "208.67.220.220"
+		evento = "auth-user-pass-verify"
 	default:
-		dns1, dns2 = "8.8.8.8", "8.8.4.4"
+		evento = "client-connect"
 	}
 
-	// Escrever ficheiros de configuração
-	serverConf := fmt.Sprintf(`port %d
+	fmt.Printf("%sTipo %s[1] script [2] plugin: %s", green(""), yellow(""), white(""))
+	var escolhaTipo int
+	fmt.Scanf("%d\n", &escolhaTipo)
+	tipo := "script"
+	if escolhaTipo == 2 {
+		tipo = "plugin"
+	}
+
+	var caminho string
+	fmt.Printf("%sCaminho para o %s/script: %s", green(""), tipo, white(""))
+	fmt.Scanln(&caminho)
+
+	fmt.Printf("%sExecutar em modo differido (defer)? %s[s/N]: %s", green(""), yellow(""), white(""))
+	var respostaDefer string
+	fmt.Scanln(&respostaDefer)
+	deferido := strings.EqualFold(respostaDefer, "s")
+
+	hooks := lerHooks()
+	hooks = append(hooks, Hook{Event: evento, Type: tipo, Path: caminho, Deferred: deferido})
+	if err := gravarHooks(hooks); err != nil {
+		fmt.Println(red("Erro ao gravar o hook:"), err)
+	} else if err := aplicarHooksATodosOsServidores(); err != nil {
+		fmt.Println(yellow("Hook gravado, mas não foi possível atualizar o server.conf:"), err)
+	} else {
+		fmt.Println(green("Hook registado com sucesso."))
+	}
+	fmt.Println(green("Pressione ENTER para continuar..."))
+	bufio.NewReader(os.Stdin).ReadBytes('\n')
+}
+
+// Regista o preset "PAM auth", que ativa openvpn-plugin-auth-pam.so com o serviço PAM indicado
+func registarHookPAMPreset() {
+	clearScreen()
+	var servico string
+	fmt.Printf("%sNome do serviço PAM %s[padrão: login]: %s", green(""), yellow(""), white(""))
+	fmt.Scanln(&servico)
+	if servico == "" {
+		servico = "login"
+	}
+
+	hooks := lerHooks()
+	hooks = append(hooks, Hook{
+		Event: "client-connect",
+		Type:  "plugin",
+		Path:  fmt.Sprintf("/usr/lib/openvpn/openvpn-plugin-auth-pam.so %s", servico),
+	})
+	if err := gravarHooks(hooks); err != nil {
+		fmt.Println(red("Erro ao gravar o hook:"), err)
+	} else if err := aplicarHooksATodosOsServidores(); err != nil {
+		fmt.Println(yellow("Hook gravado, mas não foi possível atualizar o server.conf:"), err)
+	} else {
+		fmt.Println(green("Preset de autenticação PAM registado com sucesso."))
+	}
+	fmt.Println(green("Pressione ENTER para continuar..."))
+	bufio.NewReader(os.Stdin).ReadBytes('\n')
+}
+
+// Menu para remover um hook registado, pelo seu índice na lista
+func removerHook() {
+	clearScreen()
+	hooks := lerHooks()
+	if len(hooks) == 0 {
+		fmt.Println(yellow("Nenhum hook registado."))
+		fmt.Println(green("Pressione ENTER para continuar..."))
+		bufio.NewReader(os.Stdin).ReadBytes('\n')
+		return
+	}
+	for i, h := range hooks {
+		fmt.Printf("%s[%d] %s%s (%s) -> %s\n", cyan(""), i+1, yellow(""), h.Event, h.Type, h.Path)
+	}
+	fmt.Printf("%sNúmero do hook a remover: %s", green(""), white(""))
+	var indice int
+	fmt.Scanf("%d\n", &indice)
+	if indice < 1 || indice > len(hooks) {
+		fmt.Println(red("Índice inválido."))
+		fmt.Println(green("Pressione ENTER para continuar..."))
+		bufio.NewReader(os.Stdin).ReadBytes('\n')
+		return
+	}
+	hooks = append(hooks[:indice-1], hooks[indice:]...)
+	if err := gravarHooks(hooks); err != nil {
+		fmt.Println(red("Erro ao gravar o hook:"), err)
+	} else if err := aplicarHooksATodosOsServidores(); err != nil {
+		fmt.Println(yellow("Hook removido, mas não foi possível atualizar o server.conf:"), err)
+	} else {
+		fmt.Println(green("Hook removido com sucesso."))
+	}
+	fmt.Println(green("Pressione ENTER para continuar..."))
+	bufio.NewReader(os.Stdin).ReadBytes('\n')
+}
+
+// Escolhe a próxima sub-rede /24 livre do pool 10.8.0.0/24, 10.9.0.0/24, ...,
+// verificando quais já estão efetivamente em uso (em vez de assumir que o
+// número de instâncias existentes corresponde ao próximo índice livre, o que
+// reutilizaria sub-redes já atribuídas assim que uma instância é removida)
+func proximaSubnetLivre(instancias []instanciaOpenvpn) string {
+	emUso := make(map[string]bool)
+	for _, inst := range instancias {
+		emUso[inst.Subnet] = true
+	}
+	// A sub-rede do servidor por omissão também está em uso
+	if conf, err := ioutil.ReadFile("/etc/openvpn/server.conf"); err == nil {
+		if m := regexp.MustCompile(`server (\d+\.\d+\.\d+\.\d+)`).FindStringSubmatch(string(conf)); len(m) > 1 {
+			emUso[m[1]] = true
+		}
+	}
+	for indice := 0; ; indice++ {
+		candidata := fmt.Sprintf("10.%d.0.0", 8+indice)
+		if !emUso[candidata] {
+			return candidata
+		}
+	}
+}
+
+// Lista as instâncias já criadas, lendo a porta e a sub-rede de cada server.conf
+func listarInstancias() []instanciaOpenvpn {
+	entradas, err := ioutil.ReadDir(instancesBaseDir)
+	if err != nil {
+		return nil
+	}
+
+	rePort := regexp.MustCompile(`port (\d+)`)
+	reSubnet := regexp.MustCompile(`server (\d+\.\d+\.\d+\.\d+)`)
+
+	var instancias []instanciaOpenvpn
+	for _, entrada := range entradas {
+		if !entrada.IsDir() {
+			continue
+		}
+		conf, err := ioutil.ReadFile(fmt.Sprintf("%s/%s/server.conf", instancesBaseDir, entrada.Name()))
+		if err != nil {
+			continue
+		}
+		inst := instanciaOpenvpn{Nome: entrada.Name()}
+		if m := rePort.FindStringSubmatch(string(conf)); len(m) > 1 {
+			fmt.Sscanf(m[1], "%d", &inst.Porta)
+		}
+		if m := reSubnet.FindStringSubmatch(string(conf)); len(m) > 1 {
+			inst.Subnet = m[1]
+		}
+		instancias = append(instancias, inst)
+	}
+	return instancias
+}
+
+// Devolve a interface de rede de saída a usar nas regras de MASQUERADE,
+// lida de multiflow.yaml em modo --non-interactive, com "eth0" por omissão
+func ifaceSaida() string {
+	if configHeadless != nil && configHeadless.Iface != "" {
+		return configHeadless.Iface
+	}
+	return "eth0"
+}
+
+// Adiciona (ou remove) a regra de MASQUERADE da sub-rede de uma instância de
+// forma idempotente, verificando primeiro se a regra já existe
+func aplicarMasqueradeInstancia(subnet string, adicionar bool) {
+	regra := []string{"-t", "nat", "-C", "POSTROUTING", "-s", subnet + "/24", "-o", ifaceSaida(), "-j", "MASQUERADE"}
+	existe := exec.Command("iptables", regra...).Run() == nil
+
+	if adicionar && !existe {
+		regra[2] = "-A"
+		exec.Command("iptables", regra...).Run()
+	} else if !adicionar && existe {
+		regra[2] = "-D"
+		exec.Command("iptables", regra...).Run()
+	}
+	exec.Command("sh", "-c", "iptables-save > /etc/iptables/rules.v4").Run()
+}
+
+// Cria uma nova instância de servidor OpenVPN, com diretório, server.conf,
+// client-common.txt e unidade systemd (openvpn@<nome>) próprios
+func criarInstancia() {
+	clearScreen()
+	nome := perguntarString(fmt.Sprintf("%sNome da nova instância: %s", green(""), white("")), "")
+	if nome == "" {
+		fmt.Println(red("Nome inválido."))
+		return
+	}
+
+	instancias := listarInstancias()
+	for _, inst := range instancias {
+		if inst.Nome == nome {
+			fmt.Println(red("Já existe uma instância com esse nome."))
+			return
+		}
+	}
+
+	porta := perguntarInt(fmt.Sprintf("%sQual porta para esta instância? %s[padrão: 1194]: %s", green(""), yellow(""), white("")), 1194)
+	verifPtrs(porta)
+
+	subnet := proximaSubnetLivre(instancias)
+
+	conn, err := net.Dial("udp", "8.8.8.8:80")
+	if err != nil {
+		fmt.Println(red("Não foi possível determinar o endereço IP local."), err)
+		return
+	}
+	ip := conn.LocalAddr().(*net.UDPAddr).IP.String()
+	conn.Close()
+
+	instDir := fmt.Sprintf("%s/%s", instancesBaseDir, nome)
+	os.MkdirAll(instDir, 0755)
+
+	params := configServidorParams{
+		Porta:              porta,
+		Proto:              "udp",
+		Subnet:             subnet,
+		RemoteHost:         ip,
+		ManagementSocket:   instDir + "/management.sock",
+		TmpDir:             instDir + "/tmp",
+		DNS1:               "8.8.8.8",
+		DNS2:               "8.8.4.4",
+		Auth:               "SHA512",
+		Cipher:             "AES-256-CBC",
+		DataCiphers:        "AES-256-GCM:AES-128-GCM:CHACHA20-POLY1305",
+		ControlChannelLine: linhaControlChannel(lerControlChannel()),
+		Group:              groupName,
+	}
+	ioutil.WriteFile(instDir+"/server.conf", []byte(gerarServerConf(params)), 0644)
+	ioutil.WriteFile(instDir+"/client-common.txt", []byte(gerarClientCommon(params)), 0644)
+
+	// Preparar o diretório temporário usado por scripts/plugins differidos (hooks)
+	os.MkdirAll(instDir+"/tmp", 0700)
+	exec.Command("chown", "nobody:"+groupName, instDir+"/tmp").Run()
+	if err := aplicarHooksATodosOsServidores(); err != nil {
+		fmt.Println(yellow("Aviso: não foi possível aplicar hooks previamente registados:"), err)
+	}
+
+	// A unidade openvpn@.service de base procura /etc/openvpn/<nome>.conf,
+	// por isso associa-se essa instância através de uma ligação simbólica
+	os.Remove("/etc/openvpn/" + nome + ".conf")
+	os.Symlink(instDir+"/server.conf", "/etc/openvpn/"+nome+".conf")
+
+	aplicarMasqueradeInstancia(subnet, true)
+
+	exec.Command("systemctl", "start", "openvpn@"+nome).Run()
+	exec.Command("systemctl", "enable", "openvpn@"+nome).Run()
+
+	fmt.Println(green("Instância "), nome, green(" criada com sucesso na porta "), porta, green(" (sub-rede "), subnet+"/24", green(")"))
+}
+
+// Lista todas as instâncias configuradas e o seu estado no systemd
+func listarInstanciasMenu() {
+	clearScreen()
+	instancias := listarInstancias()
+	if len(instancias) == 0 {
+		fmt.Println(yellow("Nenhuma instância configurada."))
+	}
+	for _, inst := range instancias {
+		output, _ := exec.Command("systemctl", "is-active", "openvpn@"+inst.Nome).Output()
+		estado := strings.TrimSpace(string(output))
+		fmt.Printf("%s%s%s - Porta: %d - Sub-rede: %s/24 - Estado: %s\n", cyan(""), inst.Nome, scolor(""), inst.Porta, inst.Subnet, estado)
+	}
+	fmt.Println(green("Pressione ENTER para continuar..."))
+	bufio.NewReader(os.Stdin).ReadBytes('\n')
+}
+
+// Inicia ou para uma instância existente
+func alternarInstancia() {
+	clearScreen()
+	nome := perguntarString(fmt.Sprintf("%sNome da instância: %s", green(""), white("")), "")
+	fmt.Printf("%sAção %s[1] Iniciar [2] Parar: %s", green(""), yellow(""), white(""))
+	var acao int
+	fmt.Scanf("%d\n", &acao)
+	if acao == 2 {
+		exec.Command("systemctl", "stop", "openvpn@"+nome).Run()
+		fmt.Println(green("Instância parada."))
+	} else {
+		exec.Command("systemctl", "start", "openvpn@"+nome).Run()
+		fmt.Println(green("Instância iniciada."))
+	}
+	fmt.Println(green("Pressione ENTER para continuar..."))
+	bufio.NewReader(os.Stdin).ReadBytes('\n')
+}
+
+// Remove uma instância: para/desativa o serviço, liberta a regra de
+// MASQUERADE da sua sub-rede e apaga o diretório e a ligação simbólica
+func removerInstancia() {
+	clearScreen()
+	nome := perguntarString(fmt.Sprintf("%sNome da instância a remover: %s", green(""), white("")), "")
+
+	var subnet string
+	for _, inst := range listarInstancias() {
+		if inst.Nome == nome {
+			subnet = inst.Subnet
+			break
+		}
+	}
+
+	exec.Command("systemctl", "stop", "openvpn@"+nome).Run()
+	exec.Command("systemctl", "disable", "openvpn@"+nome).Run()
+	if subnet != "" {
+		aplicarMasqueradeInstancia(subnet, false)
+	}
+	os.Remove("/etc/openvpn/" + nome + ".conf")
+	os.RemoveAll(fmt.Sprintf("%s/%s", instancesBaseDir, nome))
+
+	fmt.Println(green("Instância removida com sucesso."))
+	fmt.Println(green("Pressione ENTER para continuar..."))
+	bufio.NewReader(os.Stdin).ReadBytes('\n')
+}
+
+// Roda a chave tls-crypt-v2 do servidor sem reemitir os certificados dos
+// clientes já existentes: os clientes mantêm a sua própria chave por cliente,
+// derivada apenas no momento em que foram criados
+func rotacionarChaveTLSCryptV2() {
+	clearScreen()
+	if lerControlChannel() != "tls-crypt-v2" {
+		fmt.Println(red("Esta instalação não está configurada para tls-crypt-v2."))
+		fmt.Println(green("Pressione ENTER para continuar..."))
+		bufio.NewReader(os.Stdin).ReadBytes('\n')
+		return
+	}
+
+	if err := exec.Command("openvpn", "--tls-crypt-v2-genkey", "server", "/etc/openvpn/tls-crypt-v2-server.key").Run(); err != nil {
+		fmt.Println(red("Erro ao gerar a nova chave tls-crypt-v2 do servidor:"), err)
+		return
+	}
+	exec.Command("systemctl", "restart", "openvpn@server").Run()
+	fmt.Println(green("Chave tls-crypt-v2 do servidor rodada com sucesso. Os clientes existentes continuam válidos."))
+	fmt.Println(green("Pressione ENTER para continuar..."))
+	bufio.NewReader(os.Stdin).ReadBytes('\n')
+}
+
+// configImportada representa as diretivas reconhecidas ao importar um .ovpn/.conf existente
+type configImportada struct {
+	Remote        string
+	Port          string
+	Proto         string
+	Dev           string
+	Cipher        string
+	Auth          string
+	RemoteCertTLS string
+	CompLZO       bool
+	Verb          string
+	IsClient      bool
+	CA            string
+	Cert          string
+	Key           string
+	TLSAuth       string
+	Passthrough   []string
+}
+
+// Analisa um ficheiro .ovpn/.conf existente, reconhecendo as diretivas mais comuns
+// e preservando diretivas desconhecidas em Passthrough. Tolera comentários
+// ("#"/";") e blocos inline (<ca>...</ca>, <cert>...</cert>, etc.)
+func analisarConfigImportada(path string) (*configImportada, error) {
+	dados, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler %s: %w", path, err)
+	}
+
+	cfg := &configImportada{}
+	var blocoAtual string
+	var blocoConteudo strings.Builder
+
+	linhas := strings.Split(string(dados), "\n")
+	for _, linhaCrua := range linhas {
+		linha := strings.TrimSpace(linhaCrua)
+
+		if blocoAtual != "" {
+			if linha == fmt.Sprintf("</%s>", blocoAtual) {
+				switch blocoAtual {
+				case "ca":
+					cfg.CA = blocoConteudo.String()
+				case "cert":
+					cfg.Cert = blocoConteudo.String()
+				case "key":
+					cfg.Key = blocoConteudo.String()
+				case "tls-auth", "tls-crypt":
+					cfg.TLSAuth = blocoConteudo.String()
+				}
+				blocoAtual = ""
+				blocoConteudo.Reset()
+				continue
+			}
+			blocoConteudo.WriteString(linhaCrua)
+			blocoConteudo.WriteString("\n")
+			continue
+		}
+
+		if linha == "" || strings.HasPrefix(linha, "#") || strings.HasPrefix(linha, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(linha, "<") && strings.HasSuffix(linha, ">") && !strings.HasPrefix(linha, "</") {
+			blocoAtual = strings.TrimSuffix(strings.TrimPrefix(linha, "<"), ">")
+			continue
+		}
+
+		campos := strings.Fields(linha)
+		diretiva := campos[0]
+		switch diretiva {
+		case "remote":
+			if len(campos) >= 2 {
+				cfg.Remote = campos[1]
+			}
+			if len(campos) >= 3 {
+				cfg.Port = campos[2]
+			}
+		case "proto":
+			if len(campos) >= 2 {
+				cfg.Proto = campos[1]
+			}
+		case "port":
+			if len(campos) >= 2 {
+				cfg.Port = campos[1]
+			}
+		case "dev":
+			if len(campos) >= 2 {
+				cfg.Dev = campos[1]
+			}
+		case "cipher":
+			if len(campos) >= 2 {
+				cfg.Cipher = campos[1]
+			}
+		case "auth":
+			if len(campos) >= 2 {
+				cfg.Auth = campos[1]
+			}
+		case "remote-cert-tls":
+			if len(campos) >= 2 {
+				cfg.RemoteCertTLS = campos[1]
+			}
+		case "comp-lzo":
+			cfg.CompLZO = true
+		case "verb":
+			if len(campos) >= 2 {
+				cfg.Verb = campos[1]
+			}
+		case "client":
+			cfg.IsClient = true
+		default:
+			cfg.Passthrough = append(cfg.Passthrough, linhaCrua)
+		}
+	}
+
+	return cfg, nil
+}
+
+// Importa um ficheiro .ovpn/.conf existente e materializa-o no layout do multiflow:
+// um perfil de cliente é gravado em /etc/openvpn/client-common.txt e na PKI local,
+// enquanto uma configuração de servidor atualiza diretamente o server.conf ativo
+func importarConfig(path string) {
+	clearScreen()
+	fmt.Println(yellow("A importar configuração existente: "), path)
+
+	cfg, err := analisarConfigImportada(path)
+	if err != nil {
+		fmt.Println(red("Erro ao importar configuração:"), err)
+		fmt.Println(green("Pressione ENTER para continuar..."))
+		bufio.NewReader(os.Stdin).ReadBytes('\n')
+		return
+	}
+
+	os.MkdirAll("/etc/openvpn/easy-rsa/pki/issued", 0755)
+	os.MkdirAll("/etc/openvpn/easy-rsa/pki/private", 0755)
+
+	if cfg.CA != "" {
+		ioutil.WriteFile("/etc/openvpn/easy-rsa/pki/ca.crt", []byte(cfg.CA), 0644)
+	}
+
+	if cfg.IsClient {
+		if cfg.Cert != "" {
+			ioutil.WriteFile("/etc/openvpn/easy-rsa/pki/issued/imported.crt", []byte(cfg.Cert), 0644)
+		}
+		if cfg.Key != "" {
+			ioutil.WriteFile("/etc/openvpn/easy-rsa/pki/private/imported.key", []byte(cfg.Key), 0600)
+		}
+		if cfg.TLSAuth != "" {
+			ioutil.WriteFile("/etc/openvpn/ta.key", []byte(cfg.TLSAuth), 0644)
+		}
+
+		clientCommon := fmt.Sprintf(`client
+dev %s
+proto %s
+remote %s %s
+resolv-retry infinite
+nobind
+persist-key
+persist-tun
+remote-cert-tls %s
+auth %s
+cipher %s
+verb %s`, cfg.Dev, cfg.Proto, cfg.Remote, cfg.Port, cfg.RemoteCertTLS, cfg.Auth, cfg.Cipher, cfg.Verb)
+		if len(cfg.Passthrough) > 0 {
+			clientCommon += "\n" + strings.Join(cfg.Passthrough, "\n")
+		}
+		ioutil.WriteFile("/etc/openvpn/client-common.txt", []byte(clientCommon), 0644)
+		fmt.Println(green("Perfil de cliente importado para /etc/openvpn/client-common.txt"))
+	} else {
+		if cfg.Cert != "" {
+			ioutil.WriteFile("/etc/openvpn/easy-rsa/pki/issued/server.crt", []byte(cfg.Cert), 0644)
+		}
+		if cfg.Key != "" {
+			ioutil.WriteFile("/etc/openvpn/easy-rsa/pki/private/server.key", []byte(cfg.Key), 0600)
+		}
+		if cfg.TLSAuth != "" {
+			ioutil.WriteFile("/etc/openvpn/ta.key", []byte(cfg.TLSAuth), 0644)
+		}
+
+		serverConf := fmt.Sprintf(`port %s
+proto %s
+dev %s
+ca ca.crt
+cert server.crt
+key server.key
+auth %s
+tls-auth ta.key 0
+cipher %s
+verb %s`, cfg.Port, cfg.Proto, cfg.Dev, cfg.Auth, cfg.Cipher, cfg.Verb)
+		if len(cfg.Passthrough) > 0 {
+			serverConf += "\n" + strings.Join(cfg.Passthrough, "\n")
+		}
+		ioutil.WriteFile("/etc/openvpn/server.conf", []byte(serverConf), 0644)
+		fmt.Println(green("Configuração de servidor importada para /etc/openvpn/server.conf"))
+	}
+
+	fmt.Println(green("Pressione ENTER para continuar..."))
+	bufio.NewReader(os.Stdin).ReadBytes('\n')
+}
+
+// Grava o backend TLS escolhido em multiflowStateFile
+func gravarEstadoTLS(backend string) {
+	estado := lerEstadoMultiflow()
+	estado.TLSBackend = backend
+	gravarEstadoMultiflow(estado)
+}
+
+// Lê o backend TLS ativo a partir de multiflowStateFile, devolvendo "" se não existir
+func lerBackendTLS() string {
+	return lerEstadoMultiflow().TLSBackend
+}
+
+// Lê o estado completo do multiflow a partir de multiflowStateFile, devolvendo
+// uma estrutura vazia se o ficheiro ainda não existir
+func lerEstadoMultiflow() estadoMultiflow {
+	var estado estadoMultiflow
+	dados, err := ioutil.ReadFile(multiflowStateFile)
+	if err != nil {
+		return estado
+	}
+	json.Unmarshal(dados, &estado)
+	return estado
+}
+
+// Grava o estado completo do multiflow em multiflowStateFile
+func gravarEstadoMultiflow(estado estadoMultiflow) {
+	dados, err := json.MarshalIndent(estado, "", "  ")
+	if err != nil {
+		fmt.Println(red("Erro ao gerar o estado do multiflow:"), err)
+		return
+	}
+	if err := ioutil.WriteFile(multiflowStateFile, dados, 0644); err != nil {
+		fmt.Println(red("Erro ao gravar o estado do multiflow:"), err)
+	}
+}
+
+// Grava o modo de canal de controlo escolhido (tls-auth, tls-crypt ou tls-crypt-v2)
+func gravarControlChannel(modo string) {
+	estado := lerEstadoMultiflow()
+	estado.ControlChannel = modo
+	gravarEstadoMultiflow(estado)
+}
+
+// Lê o modo de canal de controlo ativo, devolvendo "tls-auth" se ainda não tiver sido registado
+func lerControlChannel() string {
+	modo := lerEstadoMultiflow().ControlChannel
+	if modo == "" {
+		return "tls-auth"
+	}
+	return modo
+}
+
+// Pergunta ao utilizador qual biblioteca de criptografia usar na compilação do OpenVPN,
+// ou usa o valor de multiflow.yaml (tls_backend) em modo --non-interactive
+func escolherBackendTLS() string {
+	if modoNaoInterativo {
+		if configHeadless != nil && configHeadless.TLSBackend == "mbedtls" {
+			return "mbedtls"
+		}
+		return "openssl"
+	}
+	fmt.Printf("%sQual backend TLS/crypto deseja usar? %s[1] OpenSSL (recomendado) [2] mbedTLS: %s", green(""), yellow(""), white(""))
+	var escolha int
+	fmt.Scanf("%d\n", &escolha)
+	if escolha == 2 {
+		return "mbedtls"
+	}
+	return "openssl"
+}
+
+// Pergunta ao utilizador qual modo de canal de controlo usar: tls-auth
+// (legado), tls-crypt (recomendado) ou tls-crypt-v2 (chaves por cliente), ou
+// usa o valor de multiflow.yaml (control_channel) em modo --non-interactive
+func escolherControlChannel() string {
+	if modoNaoInterativo {
+		if configHeadless != nil {
+			switch configHeadless.ControlChannel {
+			case "tls-auth":
+				return "tls-auth"
+			case "tls-crypt-v2":
+				return "tls-crypt-v2"
+			}
+		}
+		return "tls-crypt"
+	}
+	fmt.Printf("%sQual canal de controlo usar? %s[1] tls-auth [2] tls-crypt (recomendado) [3] tls-crypt-v2: %s", green(""), yellow(""), white(""))
+	var escolha int
+	fmt.Scanf("%d\n", &escolha)
+	switch escolha {
+	case 1:
+		return "tls-auth"
+	case 3:
+		return "tls-crypt-v2"
+	default:
+		return "tls-crypt"
+	}
+}
+
+// Devolve a linha de server.conf correspondente ao modo de canal de controlo
+// ativo, usando caminhos relativos ao diretório de configuração (ca.crt,
+// ta.key, etc., tal como os restantes caminhos de server.conf)
+func linhaControlChannel(modo string) string {
+	switch modo {
+	case "tls-crypt":
+		return "tls-crypt ta.key"
+	case "tls-crypt-v2":
+		return "tls-crypt-v2 tls-crypt-v2-server.key"
+	default:
+		return "tls-auth ta.key 0"
+	}
+}
+
+// configServidorParams agrupa tudo o que é necessário para gerar o
+// server.conf e o client-common.txt de um servidor OpenVPN, seja ele o
+// servidor por omissão ou uma das instâncias multi-servidor, para que ambos
+// beneficiem das mesmas funcionalidades (management, tmp-dir para hooks
+// differidos, DNS, canal de controlo configurável e negociação de cifras
+// via data-ciphers)
+type configServidorParams struct {
+	Porta              int
+	Proto              string
+	Subnet             string
+	RemoteHost         string
+	ManagementSocket   string
+	TmpDir             string
+	DNS1, DNS2         string
+	Auth, Cipher       string
+	DataCiphers        string
+	ControlChannelLine string
+	Group              string
+}
+
+// Gera o conteúdo de server.conf a partir dos parâmetros indicados
+func gerarServerConf(p configServidorParams) string {
+	return fmt.Sprintf(`port %d
 proto %s
 dev tun
 ca ca.crt
 cert server.crt
 key server.key
 dh dh.pem
-auth SHA512
-tls-auth ta.key 0
+auth %s
+%s
+management %s unix
 topology subnet
-server 10.8.0.0 255.255.255.0
+server %s 255.255.255.0
 ifconfig-pool-persist ipp.txt
 push "redirect-gateway def1 bypass-dhcp"
 push "dhcp-option DNS %s"
 push "dhcp-option DNS %s"
 keepalive 10 120
-cipher AES-256-CBC
+data-ciphers %s
+data-ciphers-fallback %s
+cipher %s
 user nobody
 group %s
 persist-key
 persist-tun
 status openvpn-status.log
 verb 3
-crl-verify crl.pem`, port, protocol, dns1, dns2, groupName)
-	ioutil.WriteFile("/etc/openvpn/server.conf", []byte(serverConf), 0644)
+tmp-dir %s
+crl-verify crl.pem`, p.Porta, p.Proto, p.Auth, p.ControlChannelLine, p.ManagementSocket, p.Subnet, p.DNS1, p.DNS2, p.DataCiphers, p.Cipher, p.Cipher, p.Group, p.TmpDir)
+}
 
-	clientCommon := fmt.Sprintf(`client
+// Gera o conteúdo de client-common.txt a partir dos parâmetros indicados
+func gerarClientCommon(p configServidorParams) string {
+	return fmt.Sprintf(`client
 dev tun
 proto %s
 remote %s %d
@@ -256,15 +1271,272 @@ nobind
 persist-key
 persist-tun
 remote-cert-tls server
-auth SHA512
-cipher AES-256-CBC
-verb 3`, protocol, ip, port)
-	ioutil.WriteFile("/etc/openvpn/client-common.txt", []byte(clientCommon), 0644)
+auth %s
+data-ciphers %s
+data-ciphers-fallback %s
+cipher %s
+verb 3`, p.Proto, p.RemoteHost, p.Porta, p.Auth, p.DataCiphers, p.Cipher, p.Cipher)
+}
+
+// Lê o ficheiro declarativo multiflow.yaml usado no modo --non-interactive.
+// Suporta um subconjunto simples de YAML (pares "chave: valor" e listas com
+// "- item"), suficiente para o formato plano deste ficheiro de configuração
+func carregarConfigHeadless(path string) (*multiflowConfig, error) {
+	dados, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler %s: %w", path, err)
+	}
+
+	cfg := &multiflowConfig{}
+	var chaveAtual string
+	for _, linhaCrua := range strings.Split(string(dados), "\n") {
+		linha := strings.TrimRight(linhaCrua, "\r")
+		aparada := strings.TrimSpace(linha)
+		if aparada == "" || strings.HasPrefix(aparada, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(aparada, "- ") {
+			item := strings.TrimSpace(strings.TrimPrefix(aparada, "- "))
+			switch chaveAtual {
+			case "dns":
+				cfg.DNS = append(cfg.DNS, item)
+			case "clients":
+				cfg.Clients = append(cfg.Clients, item)
+			}
+			continue
+		}
+
+		partes := strings.SplitN(aparada, ":", 2)
+		if len(partes) != 2 {
+			continue
+		}
+		chave := strings.TrimSpace(partes[0])
+		valor := strings.TrimSpace(partes[1])
+		chaveAtual = chave
+		if valor == "" {
+			continue
+		}
+		switch chave {
+		case "port":
+			fmt.Sscanf(valor, "%d", &cfg.Port)
+		case "proto":
+			cfg.Proto = valor
+		case "subnet":
+			cfg.Subnet = valor
+		case "iface":
+			cfg.Iface = valor
+		case "tls_backend":
+			cfg.TLSBackend = valor
+		case "control_channel":
+			cfg.ControlChannel = valor
+		}
+	}
+
+	return cfg, nil
+}
+
+// perguntarInt pede um inteiro ao utilizador; em modo --non-interactive não
+// pergunta e devolve padrao (os chamadores passam aí o valor de
+// multiflow.yaml, quando este o define)
+func perguntarInt(prompt string, padrao int) int {
+	if modoNaoInterativo {
+		return padrao
+	}
+	fmt.Print(prompt)
+	var valor int
+	fmt.Scanf("%d\n", &valor)
+	if valor == 0 {
+		return padrao
+	}
+	return valor
+}
+
+// perguntarString pede uma linha de texto ao utilizador; em modo
+// --non-interactive não pergunta e devolve padrao (os chamadores passam aí o
+// valor de multiflow.yaml, quando este o define)
+func perguntarString(prompt string, padrao string) string {
+	if modoNaoInterativo {
+		return padrao
+	}
+	fmt.Print(prompt)
+	var valor string
+	fmt.Scanln(&valor)
+	if valor == "" {
+		return padrao
+	}
+	return valor
+}
+
+// Função principal de instalação do OpenVPN
+func instalarOpenvpn() {
+	// Detetar IP público (simplificado)
+	conn, err := net.Dial("udp", "8.8.8.8:80")
+	if err != nil {
+		fmt.Println(red("Não foi possível determinar o endereço IP local."), err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+	ip := conn.LocalAddr().(*net.UDPAddr).IP.String()
+
+	clearScreen()
+	fmt.Println("\033[44;1;37m INSTALADOR OPENVPN \033[0m")
+	fmt.Println(green("A iniciar a instalação do OpenVPN..."))
+
+	// Escolher o backend TLS/crypto (OpenSSL ou mbedTLS) antes de instalar dependências
+	backendTLS := escolherBackendTLS()
+
+	// Instalação de dependências
+	if osType == "debian" {
+		cryptoDevPkg := "libssl-dev"
+		if backendTLS == "mbedtls" {
+			cryptoDevPkg = "libmbedtls-dev"
+		}
+		funBar(fmt.Sprintf("apt-get update && apt-get install -y build-essential autoconf automake libtool pkg-config liblz4-dev liblzo2-dev %s iptables openssl ca-certificates wget tar", cryptoDevPkg))
+	} else {
+		cryptoDevPkg := "openssl-devel"
+		if backendTLS == "mbedtls" {
+			cryptoDevPkg = "mbedtls-devel"
+		}
+		funBar(fmt.Sprintf("yum groupinstall -y 'Development Tools' && yum install -y epel-release && yum install -y autoconf automake libtool pkgconfig lz4-devel lzo-devel %s iptables openssl ca-certificates wget tar", cryptoDevPkg))
+	}
+
+	// Baixar e instalar OpenVPN
+	fmt.Println(yellow("A baixar e compilar o OpenVPN..."))
+	funBar(fmt.Sprintf("wget -O /tmp/openvpn.tar.gz https://swupdate.openvpn.org/community/releases/openvpn-%s.tar.gz && tar -xzf /tmp/openvpn.tar.gz -C /tmp && cd /tmp/openvpn-%s && ./configure --with-crypto-library=%s && make && make install", OPENVPN_VERSION, OPENVPN_VERSION, backendTLS))
+
+	// Baixar e configurar EasyRSA
+	fmt.Println(yellow("A baixar e configurar o EasyRSA..."))
+	os.MkdirAll("/etc/openvpn/easy-rsa", 0755)
+	funBar(fmt.Sprintf("wget -O /tmp/easyrsa.tgz https://github.com/OpenVPN/easy-rsa/releases/download/v%s/EasyRSA-%s.tgz && tar -xzf /tmp/easyrsa.tgz -C /tmp && mv /tmp/EasyRSA-%s/* /etc/openvpn/easy-rsa/", EASYRSA_VERSION, EASYRSA_VERSION, EASYRSA_VERSION))
+	
+	os.Chdir("/etc/openvpn/easy-rsa/")
+	if _, err := os.Stat("pki/issued/server.crt"); err == nil {
+		fmt.Println(yellow("PKI já inicializada, a reaproveitar server.crt existente..."))
+	} else {
+		funBar("./easyrsa init-pki && ./easyrsa --batch build-ca nopass && ./easyrsa gen-dh && ./easyrsa build-server-full server nopass && ./easyrsa build-client-full client nopass && ./easyrsa gen-crl")
+	}
+
+	// Mover ficheiros e configurar permissões
+	filesToCopy := []string{"pki/ca.crt", "pki/private/ca.key", "pki/dh.pem", "pki/issued/server.crt", "pki/private/server.key", "pki/crl.pem"}
+	for _, f := range filesToCopy {
+		exec.Command("cp", f, "/etc/openvpn").Run()
+	}
+	exec.Command("chown", "nobody:"+groupName, "/etc/openvpn/crl.pem").Run()
+
+	// Escolher o modo do canal de controlo e gerar a chave estática correspondente.
+	// Tal como a PKI acima, a chave só é gerada se ainda não existir, para que
+	// reexecutar a instalação não invalide as chaves já distribuídas aos clientes
+	controlChannel := escolherControlChannel()
+	switch controlChannel {
+	case "tls-crypt-v2":
+		if _, err := os.Stat("/etc/openvpn/tls-crypt-v2-server.key"); err != nil {
+			exec.Command("openvpn", "--tls-crypt-v2-genkey", "server", "/etc/openvpn/tls-crypt-v2-server.key").Run()
+		}
+	default:
+		// tls-auth e tls-crypt usam o mesmo formato de chave estática
+		if _, err := os.Stat("/etc/openvpn/ta.key"); err != nil {
+			exec.Command("openvpn", "--genkey", "--secret", "/etc/openvpn/ta.key").Run()
+		}
+	}
+
+	// Configuração interativa (ou lida de multiflow.yaml em modo --non-interactive)
+	portPadrao := 1194
+	protoPadrao := "udp"
+	if configHeadless != nil {
+		if configHeadless.Port != 0 {
+			portPadrao = configHeadless.Port
+		}
+		if configHeadless.Proto != "" {
+			protoPadrao = configHeadless.Proto
+		}
+	}
+
+	port := perguntarInt(fmt.Sprintf("%sQual porta deseja usar para o OpenVPN? %s[padrão: 1194]: %s", green(""), yellow(""), white("")), portPadrao)
+	verifPtrs(port)
+
+	protocol := strings.ToLower(perguntarString(fmt.Sprintf("%sQual protocolo? %s[udp] (recomendado) [tcp]: %s", green(""), yellow(""), white("")), protoPadrao))
+	if protocol != "tcp" {
+		protocol = "udp"
+	}
+
+	var dns1, dns2 string
+	if configHeadless != nil && len(configHeadless.DNS) > 0 {
+		dns1 = configHeadless.DNS[0]
+		if len(configHeadless.DNS) > 1 {
+			dns2 = configHeadless.DNS[1]
+		} else {
+			dns2 = dns1
+		}
+	} else if modoNaoInterativo {
+		// Sem "dns:" em multiflow.yaml: usa o Google DNS por omissão, tal
+		// como a opção [1] do prompt interativo
+		dns1, dns2 = "8.8.8.8", "8.8.4.4"
+	} else {
+		fmt.Printf("%sQual DNS usar? %s[1] Google (padrão) [2] Cloudflare [3] OpenDNS: %s", green(""), yellow(""), white(""))
+		var dnsChoice int
+		fmt.Scanf("%d\n", &dnsChoice)
+		switch dnsChoice {
+		case 2:
+			dns1, dns2 = "1.1.1.1", "1.0.0.1"
+		case 3:
+			dns1, dns2 = "208.67.222.222", "208.67.220.220"
+		default:
+			dns1, dns2 = "8.8.8.8", "8.8.4.4"
+		}
+	}
+
+	// Cipher e auth suportados dependem do backend TLS escolhido: builds mbedTLS
+	// mais comuns (ex.: os ports openvpn-mbedtls do FreeBSD) não suportam SHA512
+	// combinado com AES-256-CBC, por isso usamos SHA256 nesse caso
+	cipher := "AES-256-CBC"
+	auth := "SHA512"
+	if backendTLS == "mbedtls" {
+		auth = "SHA256"
+	}
+	dataCiphers := "AES-256-GCM:AES-128-GCM:CHACHA20-POLY1305"
+
+	subnet := "10.8.0.0"
+	if configHeadless != nil && configHeadless.Subnet != "" {
+		subnet = configHeadless.Subnet
+	}
+
+	params := configServidorParams{
+		Porta:              port,
+		Proto:              protocol,
+		Subnet:             subnet,
+		RemoteHost:         ip,
+		ManagementSocket:   managementSocket,
+		TmpDir:             "/etc/openvpn/tmp",
+		DNS1:               dns1,
+		DNS2:               dns2,
+		Auth:               auth,
+		Cipher:             cipher,
+		DataCiphers:        dataCiphers,
+		ControlChannelLine: linhaControlChannel(controlChannel),
+		Group:              groupName,
+	}
+
+	// Escrever ficheiros de configuração
+	ioutil.WriteFile("/etc/openvpn/server.conf", []byte(gerarServerConf(params)), 0644)
+
+	// Preparar o diretório temporário usado por scripts/plugins differidos (hooks)
+	os.MkdirAll("/etc/openvpn/tmp", 0700)
+	exec.Command("chown", "nobody:"+groupName, "/etc/openvpn/tmp").Run()
+	if err := aplicarHooksATodosOsServidores(); err != nil {
+		fmt.Println(yellow("Aviso: não foi possível aplicar hooks previamente registados:"), err)
+	}
+
+	ioutil.WriteFile("/etc/openvpn/client-common.txt", []byte(gerarClientCommon(params)), 0644)
+
+	// Registar o backend TLS e o canal de controlo escolhidos para uso futuro
+	gravarEstadoTLS(backendTLS)
+	gravarControlChannel(controlChannel)
 
 	// Configurar Firewall
 	exec.Command("sh", "-c", "echo 'net.ipv4.ip_forward=1' > /etc/sysctl.d/30-openvpn-forward.conf").Run()
 	exec.Command("sysctl", "-p", "/etc/sysctl.d/30-openvpn-forward.conf").Run()
-	exec.Command("iptables", "-t", "nat", "-A", "POSTROUTING", "-s", "10.8.0.0/24", "-o", "eth0", "-j", "MASQUERADE").Run()
+	exec.Command("iptables", "-t", "nat", "-A", "POSTROUTING", "-s", subnet+"/24", "-o", ifaceSaida(), "-j", "MASQUERADE").Run()
 	exec.Command("iptables-save", ">", "/etc/iptables/rules.v4").Run()
 
 	// Iniciar e habilitar serviço
@@ -272,16 +1544,26 @@ verb 3`, protocol, ip, port)
 	exec.Command("systemctl", "enable", "openvpn@server").Run()
 
 	fmt.Println(green("OpenVPN instalado com sucesso!"))
-	fmt.Println(yellow("A criar o primeiro cliente..."))
-	var client string
-	fmt.Printf("%sNome do primeiro cliente: %s", green(""), white(""))
-	fmt.Scanln(&client)
+
+	var clientes []string
+	if configHeadless != nil && len(configHeadless.Clients) > 0 {
+		clientes = configHeadless.Clients
+	} else {
+		fmt.Println(yellow("A criar o primeiro cliente..."))
+		clientes = []string{perguntarString(fmt.Sprintf("%sNome do primeiro cliente: %s", green(""), white("")), "client")}
+	}
+
 	os.Chdir("/etc/openvpn/easy-rsa/")
-	exec.Command("./easyrsa", "build-client-full", client, "nopass").Run()
-	newClient(client)
-	fmt.Println(green("Cliente criado! O ficheiro está em: ") + white(fmt.Sprintf("/root/%s.ovpn", client)))
-	fmt.Println(green("Pressione ENTER para continuar..."))
-	bufio.NewReader(os.Stdin).ReadBytes('\n')
+	for _, client := range clientes {
+		exec.Command("./easyrsa", "--batch", "build-client-full", client, "nopass").Run()
+		newClient(client, "")
+		fmt.Println(green("Cliente criado! O ficheiro está em: ") + white(fmt.Sprintf("/root/%s.ovpn", client)))
+	}
+
+	if !modoNaoInterativo {
+		fmt.Println(green("Pressione ENTER para continuar..."))
+		bufio.NewReader(os.Stdin).ReadBytes('\n')
+	}
 }
 
 // Função para remover o OpenVPN
@@ -291,7 +1573,18 @@ func removerOpenvpn() {
 	fmt.Println(yellow("A parar o serviço OpenVPN..."))
 	exec.Command("systemctl", "stop", "openvpn@server").Run()
 	exec.Command("systemctl", "disable", "openvpn@server").Run()
+
+	fmt.Println(yellow("A parar as instâncias adicionais..."))
+	for _, inst := range listarInstancias() {
+		exec.Command("systemctl", "stop", "openvpn@"+inst.Nome).Run()
+		exec.Command("systemctl", "disable", "openvpn@"+inst.Nome).Run()
+		if inst.Subnet != "" {
+			aplicarMasqueradeInstancia(inst.Subnet, false)
+		}
+	}
+
 	fmt.Println(yellow("A remover ficheiros de configuração..."))
+	os.Remove(multiflowStateFile)
 	os.RemoveAll("/etc/openvpn")
 	os.RemoveAll("/etc/iptables/rules.v4")
 
@@ -309,9 +1602,17 @@ func removerOpenvpn() {
 func criarCliente() {
 	clearScreen()
 	fmt.Println(yellow("A criar um novo cliente..."))
-	var client string
-	fmt.Printf("%sNome do novo cliente: %s", green(""), white(""))
-	fmt.Scanln(&client)
+	client := perguntarString(fmt.Sprintf("%sNome do novo cliente: %s", green(""), white("")), "")
+
+	instancia := ""
+	if instancias := listarInstancias(); len(instancias) > 0 {
+		fmt.Println(yellow("Instâncias disponíveis (deixe em branco para o servidor padrão):"))
+		for _, inst := range instancias {
+			fmt.Printf("  %s%s%s - Porta: %d\n", cyan(""), inst.Nome, scolor(""), inst.Porta)
+		}
+		instancia = perguntarString(fmt.Sprintf("%sInstância a que o cliente pertence: %s", green(""), white("")), "")
+	}
+
 	os.Chdir("/etc/openvpn/easy-rsa/")
 	// Usar --batch para evitar prompts interativos
 	cmd := exec.Command("./easyrsa", "--batch", "build-client-full", client, "nopass")
@@ -319,7 +1620,7 @@ func criarCliente() {
 		fmt.Println(red("Falha ao criar o cliente com EasyRSA:"), err)
 		return
 	}
-	newClient(client)
+	newClient(client, instancia)
 	fmt.Println(green("Cliente criado! O ficheiro está em: ") + white(fmt.Sprintf("/root/%s.ovpn", client)))
 	fmt.Println(green("Pressione ENTER para continuar..."))
 	bufio.NewReader(os.Stdin).ReadBytes('\n')
@@ -346,14 +1647,30 @@ func menuPrincipal() {
 			if len(match) > 1 {
 				port = match[1]
 			}
-			fmt.Printf("%sStatus: %sOpenVPN Ativo - Porta: %s%s%s\n", green(""), white(""), green(""), port, scolor(""))
+			backend := lerBackendTLS()
+			if backend == "" {
+				backend = "desconhecido"
+			}
+			fmt.Printf("%sStatus: %sOpenVPN Ativo - Porta: %s%s%s - Backend TLS: %s%s%s\n", green(""), white(""), green(""), port, scolor(""), green(""), backend, scolor(""))
 			fmt.Println("")
 			fmt.Printf("%s[1] %sCriar Cliente\n", cyan(""), yellow(""))
 			fmt.Printf("%s[2] %sRemover OpenVPN\n", cyan(""), yellow(""))
+			fmt.Printf("%s[3] %sListar Clientes Conectados\n", cyan(""), yellow(""))
+			fmt.Printf("%s[4] %sDesconectar Cliente\n", cyan(""), yellow(""))
+			fmt.Printf("%s[5] %sRevogar Certificado de Cliente\n", cyan(""), yellow(""))
+			fmt.Printf("%s[6] %sRegistar Hook\n", cyan(""), yellow(""))
+			fmt.Printf("%s[7] %sRegistar Preset de Autenticação PAM\n", cyan(""), yellow(""))
+			fmt.Printf("%s[8] %sRemover Hook\n", cyan(""), yellow(""))
+			fmt.Printf("%s[9] %sCriar Instância\n", cyan(""), yellow(""))
+			fmt.Printf("%s[10] %sListar Instâncias\n", cyan(""), yellow(""))
+			fmt.Printf("%s[11] %sIniciar/Parar Instância\n", cyan(""), yellow(""))
+			fmt.Printf("%s[12] %sRemover Instância\n", cyan(""), yellow(""))
+			fmt.Printf("%s[13] %sRodar Chave tls-crypt-v2 do Servidor\n", cyan(""), yellow(""))
 		} else {
 			fmt.Printf("%sStatus: %sOpenVPN Não Instalado%s\n", red(""), white(""), scolor(""))
 			fmt.Println("")
 			fmt.Printf("%s[1] %sInstalar OpenVPN\n", cyan(""), yellow(""))
+			fmt.Printf("%s[2] %sImportar Configuração Existente\n", cyan(""), yellow(""))
 		}
 		fmt.Printf("%s[0] %sSair\n", cyan(""), yellow(""))
 		fmt.Println("")
@@ -367,6 +1684,28 @@ func menuPrincipal() {
 				criarCliente()
 			case 2:
 				removerOpenvpn()
+			case 3:
+				listarClientesConectados()
+			case 4:
+				desconectarCliente()
+			case 5:
+				revogarCertificadoCliente()
+			case 6:
+				registarHook()
+			case 7:
+				registarHookPAMPreset()
+			case 8:
+				removerHook()
+			case 9:
+				criarInstancia()
+			case 10:
+				listarInstanciasMenu()
+			case 11:
+				alternarInstancia()
+			case 12:
+				removerInstancia()
+			case 13:
+				rotacionarChaveTLSCryptV2()
 			case 0:
 				clearScreen()
 				os.Exit(0)
@@ -378,6 +1717,11 @@ func menuPrincipal() {
 			switch option {
 			case 1:
 				instalarOpenvpn()
+			case 2:
+				var caminho string
+				fmt.Printf("%sCaminho do ficheiro .ovpn/.conf a importar: %s", green(""), white(""))
+				fmt.Scanln(&caminho)
+				importarConfig(caminho)
 			case 0:
 				clearScreen()
 				os.Exit(0)
@@ -390,6 +1734,27 @@ func menuPrincipal() {
 }
 
 func main() {
+	naoInterativo := flag.Bool("non-interactive", false, "corre sem prompts, lendo toda a configuração do --config")
+	caminhoConfig := flag.String("config", "", "caminho para o multiflow.yaml usado em modo --non-interactive")
+	flag.Parse()
+
 	checkInitial()
+
+	if *naoInterativo {
+		modoNaoInterativo = true
+		if *caminhoConfig == "" {
+			fmt.Println(red("--non-interactive requer --config <ficheiro>"))
+			os.Exit(1)
+		}
+		cfg, err := carregarConfigHeadless(*caminhoConfig)
+		if err != nil {
+			fmt.Println(red("Erro ao carregar a configuração:"), err)
+			os.Exit(1)
+		}
+		configHeadless = cfg
+		instalarOpenvpn()
+		return
+	}
+
 	menuPrincipal()
 }